@@ -0,0 +1,34 @@
+// Package accounts implements a double-entry accounting ledger: every
+// state change is posted as a balanced debit/credit pair against named
+// accounts, replacing a single pair of scalar balances with a real audit
+// trail.
+package accounts
+
+// Type distinguishes accounts that are allowed to go negative (they
+// represent value owed by the ledger, such as a payout sink) from normal
+// asset accounts, which PostTransaction refuses to overdraw.
+type Type string
+
+const (
+	Asset     Type = "asset"
+	Liability Type = "liability"
+)
+
+// Default account IDs used by the HTTP handlers' /pay, /save and
+// /transfer wrappers.
+const (
+	Wallet   = "wallet"
+	Bank     = "bank"
+	External = "external"
+)
+
+// Account is a named balance. Wallet and Bank are normal asset accounts;
+// a liability account such as External models value leaving the ledger
+// (e.g. a payment to a merchant) without needing a matching real-world
+// account on the other side.
+type Account struct {
+	ID      string `json:"id"`
+	Owner   string `json:"owner"`
+	Type    Type   `json:"type"`
+	Balance int64  `json:"balance"`
+}
@@ -0,0 +1,376 @@
+package accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/storage"
+)
+
+var (
+	// ErrAccountNotFound is returned by Account and PostTransaction when a
+	// referenced account ID does not exist.
+	ErrAccountNotFound = errors.New("accounts: account not found")
+	// ErrAccountExists is returned by CreateAccount for a duplicate ID.
+	ErrAccountExists = errors.New("accounts: account already exists")
+	// ErrOverdrawn is returned by PostTransaction when a posting would
+	// drive a non-liability account's balance below zero.
+	ErrOverdrawn = errors.New("accounts: insufficient funds")
+)
+
+// bookSnapshot is the compacted state written every snapshotEvery
+// postings so startup replay only has to walk the postings log since.
+// Covered is the WAL sequence number up to and including which Accounts
+// already reflects every posting, so replay can skip entries that would
+// otherwise be double-applied if a crash lands between SaveSnapshot and
+// the WAL truncation that is meant to follow it.
+type bookSnapshot struct {
+	Accounts map[string]Account `json:"accounts"`
+	Covered  uint64             `json:"covered"`
+}
+
+// Book is the double-entry ledger: every balance change is posted as one
+// or more Postings via PostTransaction, which is the only way callers may
+// mutate an Account's Balance.
+type Book struct {
+	mu            sync.Mutex
+	accounts      map[string]*Account
+	wal           *storage.WAL[Posting]
+	accountsWAL   *storage.WAL[Account]
+	snapshotPath  string
+	snapshotEvery uint64
+	sinceSnapshot uint64
+}
+
+// NewMemoryBook returns a Book seeded with the given accounts that keeps
+// no audit trail; it does not survive a restart.
+func NewMemoryBook(seed ...Account) *Book {
+	b := &Book{accounts: map[string]*Account{}}
+	for _, a := range seed {
+		a := a
+		b.accounts[a.ID] = &a
+	}
+	return b
+}
+
+// NewFileBook opens (or creates) a postings log and account snapshot under
+// dir, replays them to reconstruct current balances, and returns a Book
+// ready to serve traffic. seed accounts are created whenever no snapshot
+// exists yet, and the postings log is replayed on top of them; once a
+// snapshot exists, seed is ignored. snapshotEvery controls how many postings accumulate before the
+// account balances are compacted into a new snapshot and the log reset.
+func NewFileBook(dir string, snapshotEvery uint64, seed ...Account) (*Book, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("accounts: create dir: %w", err)
+	}
+
+	walPath := dir + "/postings.jsonl"
+	accountsWALPath := dir + "/accounts.jsonl"
+	b := &Book{
+		accounts:      map[string]*Account{},
+		snapshotPath:  dir + "/accounts_snapshot.json",
+		snapshotEvery: snapshotEvery,
+	}
+
+	snap, hasSnapshot, err := storage.LoadSnapshot[bookSnapshot](b.snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("accounts: load snapshot: %w", err)
+	}
+	var covered uint64
+	if hasSnapshot {
+		covered = snap.Covered
+		for id, a := range snap.Accounts {
+			a := a
+			b.accounts[id] = &a
+		}
+	} else {
+		// Nothing to replay against yet: the seed accounts must exist
+		// before any posting in the log can be applied to them.
+		for _, a := range seed {
+			a := a
+			b.accounts[a.ID] = &a
+		}
+	}
+
+	// Accounts created after the last snapshot (e.g. CreateAccount or
+	// EnsureAccount provisioning a caller's wallet on first use) only
+	// exist because this log recorded them; replay it before the
+	// postings log so any posting referencing one of them has an account
+	// to apply against. Idempotent by ID rather than by covered sequence:
+	// an account the snapshot already has is simply left alone, so a
+	// crash between a compaction's SaveSnapshot and its log resets can't
+	// cause a duplicate-account error here the way it could for balances.
+	accountsLastSeq, err := storage.ReplayWAL[Account](accountsWALPath, func(seq uint64, a Account) error {
+		if _, exists := b.accounts[a.ID]; exists {
+			return nil
+		}
+		b.accounts[a.ID] = &a
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("accounts: replay account creations: %w", err)
+	}
+	accountsWAL, err := storage.OpenWAL[Account](accountsWALPath, accountsLastSeq)
+	if err != nil {
+		return nil, fmt.Errorf("accounts: open account creations log: %w", err)
+	}
+	b.accountsWAL = accountsWAL
+
+	// Entries at or below covered are already reflected in the snapshot
+	// (compact only truncates the WAL after the snapshot is durable, so a
+	// crash in between can leave them both on disk); replaying them again
+	// would double their effect on the loaded balances.
+	lastSeq, err := storage.ReplayWAL[Posting](walPath, func(seq uint64, p Posting) error {
+		if seq <= covered {
+			return nil
+		}
+		if err := b.applyPosting(p); err != nil {
+			return fmt.Errorf("corrupt postings log at seq %d: %w", seq, err)
+		}
+		b.sinceSnapshot++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("accounts: replay postings: %w", err)
+	}
+	if lastSeq < covered {
+		lastSeq = covered
+	}
+
+	wal, err := storage.OpenWAL[Posting](walPath, lastSeq)
+	if err != nil {
+		return nil, fmt.Errorf("accounts: open postings log: %w", err)
+	}
+	b.wal = wal
+
+	return b, nil
+}
+
+// CreateAccount registers a new account with the given opening balance.
+func (b *Book) CreateAccount(id, owner string, typ Type, initialBalance int64) (Account, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, exists := b.accounts[id]; exists {
+		return Account{}, ErrAccountExists
+	}
+
+	a := Account{ID: id, Owner: owner, Type: typ, Balance: initialBalance}
+	if err := b.journalAccount(a); err != nil {
+		return Account{}, fmt.Errorf("accounts: append account creations log: %w", err)
+	}
+	b.accounts[id] = &a
+	return a, nil
+}
+
+// EnsureAccount returns the account with the given ID, creating it with
+// a zero balance and the given owner/type first if it does not already
+// exist. It is meant for lazily provisioning per-caller accounts (e.g.
+// one wallet per authenticated user) on first use.
+func (b *Book) EnsureAccount(id, owner string, typ Type) (Account, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if a, ok := b.accounts[id]; ok {
+		return *a, nil
+	}
+
+	a := Account{ID: id, Owner: owner, Type: typ, Balance: 0}
+	if err := b.journalAccount(a); err != nil {
+		return Account{}, fmt.Errorf("accounts: append account creations log: %w", err)
+	}
+	b.accounts[id] = &a
+	return a, nil
+}
+
+// journalAccount durably records the creation of a (newly-created, not
+// yet stored in b.accounts) account before the caller makes it visible,
+// so a restart can recreate accounts a snapshot predates instead of
+// failing to replay any posting that references one. A no-op for a
+// non-persistent Book. Callers must hold b.mu.
+func (b *Book) journalAccount(a Account) error {
+	if b.accountsWAL == nil {
+		return nil
+	}
+	_, err := b.accountsWAL.Append(a)
+	return err
+}
+
+// Account returns the current state of the account with the given ID.
+func (b *Book) Account(id string) (Account, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	a, ok := b.accounts[id]
+	if !ok {
+		return Account{}, ErrAccountNotFound
+	}
+	return *a, nil
+}
+
+// Accounts returns every account, ordered by ID.
+func (b *Book) Accounts() []Account {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make([]Account, 0, len(b.accounts))
+	for _, a := range b.accounts {
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// PostTransaction atomically applies one or more Postings. Every posting
+// in the batch must reference existing accounts and a positive amount;
+// the whole batch is rejected if any debit would leave a non-liability
+// account negative. On success every posting is durably appended to the
+// audit trail (if one is configured) before any balance is committed.
+func (b *Book) PostTransaction(ctx context.Context, postings ...Posting) (txID string, err error) {
+	if len(postings) == 0 {
+		return "", errors.New("accounts: transaction has no postings")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	txID = generateTxID()
+	now := time.Now()
+
+	// Project the balances first so the whole batch either commits or is
+	// rejected; nothing partially applies.
+	projected := make(map[string]int64, len(b.accounts))
+	for id, a := range b.accounts {
+		projected[id] = a.Balance
+	}
+
+	for i := range postings {
+		p := &postings[i]
+		if p.Amount <= 0 {
+			return "", fmt.Errorf("accounts: posting %d: amount must be positive", i)
+		}
+		if p.DebitAcct == p.CreditAcct {
+			return "", fmt.Errorf("accounts: posting %d: debit and credit account must differ", i)
+		}
+		debit, ok := b.accounts[p.DebitAcct]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrAccountNotFound, p.DebitAcct)
+		}
+		if _, ok := b.accounts[p.CreditAcct]; !ok {
+			return "", fmt.Errorf("%w: %s", ErrAccountNotFound, p.CreditAcct)
+		}
+
+		projected[p.DebitAcct] -= p.Amount
+		projected[p.CreditAcct] += p.Amount
+
+		if projected[p.DebitAcct] < 0 && debit.Type != Liability {
+			return "", fmt.Errorf("%w: account %s", ErrOverdrawn, p.DebitAcct)
+		}
+
+		p.TxID = txID
+		p.Timestamp = now
+	}
+
+	if b.wal != nil {
+		for _, p := range postings {
+			if _, err := b.wal.Append(p); err != nil {
+				return "", fmt.Errorf("accounts: append postings log: %w", err)
+			}
+			b.sinceSnapshot++
+		}
+	}
+
+	for id, balance := range projected {
+		b.accounts[id].Balance = balance
+	}
+
+	if b.wal != nil && b.snapshotEvery > 0 && b.sinceSnapshot >= b.snapshotEvery {
+		if err := b.compact(); err != nil {
+			return txID, fmt.Errorf("accounts: compact: %w", err)
+		}
+	}
+
+	return txID, nil
+}
+
+// applyPosting replays a previously-accepted posting without
+// re-validating it, since it was already accepted once.
+func (b *Book) applyPosting(p Posting) error {
+	debit, ok := b.accounts[p.DebitAcct]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrAccountNotFound, p.DebitAcct)
+	}
+	credit, ok := b.accounts[p.CreditAcct]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrAccountNotFound, p.CreditAcct)
+	}
+	debit.Balance -= p.Amount
+	credit.Balance += p.Amount
+	return nil
+}
+
+// compact writes the current account balances to the snapshot file,
+// recording the WAL sequence number they cover, and truncates the
+// postings log now that every entry up to that point is represented in
+// the snapshot. Recording the covered sequence (rather than relying on
+// the truncation alone) means a crash between the two steps still
+// recovers correctly: replay skips anything at or below it instead of
+// double-applying postings the snapshot already counted. The account
+// creations log is truncated too, since every account it held is now in
+// the snapshot; replaying it from empty after a crash mid-compaction is
+// safe because journalAccount's replay is idempotent by ID, not by
+// sequence.
+func (b *Book) compact() error {
+	covered := b.wal.Seq()
+	snap := bookSnapshot{Accounts: make(map[string]Account, len(b.accounts)), Covered: covered}
+	for id, a := range b.accounts {
+		snap.Accounts[id] = *a
+	}
+	if err := storage.SaveSnapshot(b.snapshotPath, snap); err != nil {
+		return err
+	}
+	if err := b.wal.Reset(covered); err != nil {
+		return err
+	}
+	if err := b.accountsWAL.Reset(b.accountsWAL.Seq()); err != nil {
+		return err
+	}
+	b.sinceSnapshot = 0
+	return nil
+}
+
+// WALOffset reports the sequence number of the last posting durably
+// applied, or 0 for a non-persistent Book.
+func (b *Book) WALOffset() uint64 {
+	if b.wal == nil {
+		return 0
+	}
+	return b.wal.Seq()
+}
+
+// Close releases the underlying postings and account creations log file
+// handles, if any.
+func (b *Book) Close() error {
+	if b.wal == nil {
+		return nil
+	}
+	if err := b.accountsWAL.Close(); err != nil {
+		return err
+	}
+	return b.wal.Close()
+}
+
+func generateTxID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "fallback-tx-id"
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
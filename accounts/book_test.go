@@ -0,0 +1,246 @@
+package accounts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+func testBook() *Book {
+	return NewMemoryBook(
+		Account{ID: Wallet, Owner: "customer", Type: Asset, Balance: 1000},
+		Account{ID: Bank, Owner: "customer", Type: Asset, Balance: 0},
+		Account{ID: External, Owner: "system", Type: Liability, Balance: 0},
+	)
+}
+
+func TestPostTransactionMovesBalances(t *testing.T) {
+	b := testBook()
+	ctx := context.Background()
+
+	if _, err := b.PostTransaction(ctx, Posting{DebitAcct: Wallet, CreditAcct: Bank, Amount: 200, Currency: "USD"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wallet, _ := b.Account(Wallet)
+	bank, _ := b.Account(Bank)
+	if wallet.Balance != 800 || bank.Balance != 200 {
+		t.Errorf("expected wallet=800 bank=200, got wallet=%d bank=%d", wallet.Balance, bank.Balance)
+	}
+}
+
+func TestPostTransactionRejectsOverdraw(t *testing.T) {
+	b := testBook()
+	ctx := context.Background()
+
+	_, err := b.PostTransaction(ctx, Posting{DebitAcct: Wallet, CreditAcct: Bank, Amount: 5000, Currency: "USD"})
+	if !errors.Is(err, ErrOverdrawn) {
+		t.Fatalf("expected ErrOverdrawn, got %v", err)
+	}
+
+	wallet, _ := b.Account(Wallet)
+	if wallet.Balance != 1000 {
+		t.Errorf("rejected transaction must not change balance, got %d", wallet.Balance)
+	}
+}
+
+func TestPostTransactionAllowsLiabilityOverdraw(t *testing.T) {
+	b := testBook()
+	ctx := context.Background()
+
+	if _, err := b.PostTransaction(ctx, Posting{DebitAcct: Wallet, CreditAcct: External, Amount: 150, Currency: "USD"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	external, _ := b.Account(External)
+	if external.Balance != 150 {
+		t.Errorf("expected external balance 150, got %d", external.Balance)
+	}
+}
+
+func TestPostTransactionUnknownAccount(t *testing.T) {
+	b := testBook()
+	ctx := context.Background()
+
+	_, err := b.PostTransaction(ctx, Posting{DebitAcct: Wallet, CreditAcct: "ghost", Amount: 10, Currency: "USD"})
+	if !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
+	}
+}
+
+func TestFileBookPersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	seed := []Account{
+		{ID: Wallet, Owner: "customer", Type: Asset, Balance: 1000},
+		{ID: Bank, Owner: "customer", Type: Asset, Balance: 0},
+		{ID: External, Owner: "system", Type: Liability, Balance: 0},
+	}
+
+	b, err := NewFileBook(dir, 100, seed...)
+	if err != nil {
+		t.Fatalf("NewFileBook: %v", err)
+	}
+	if _, err := b.PostTransaction(ctx, Posting{DebitAcct: Wallet, CreditAcct: Bank, Amount: 200, Currency: "USD"}); err != nil {
+		t.Fatalf("PostTransaction: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := NewFileBook(dir, 100, seed...)
+	if err != nil {
+		t.Fatalf("reopen NewFileBook: %v", err)
+	}
+	defer reopened.Close()
+
+	wallet, _ := reopened.Account(Wallet)
+	bank, _ := reopened.Account(Bank)
+	if wallet.Balance != 800 || bank.Balance != 200 {
+		t.Errorf("expected replayed wallet=800 bank=200, got wallet=%d bank=%d", wallet.Balance, bank.Balance)
+	}
+}
+
+func TestFileBookSkipsWALEntriesCoveredBySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	seed := []Account{
+		{ID: Wallet, Owner: "customer", Type: Asset, Balance: 1000},
+		{ID: Bank, Owner: "customer", Type: Asset, Balance: 0},
+		{ID: External, Owner: "system", Type: Liability, Balance: 0},
+	}
+
+	// snapshotEvery: 1 forces a compaction (and WAL truncation) right
+	// after the single posting below.
+	b, err := NewFileBook(dir, 1, seed...)
+	if err != nil {
+		t.Fatalf("NewFileBook: %v", err)
+	}
+	if _, err := b.PostTransaction(ctx, Posting{DebitAcct: Wallet, CreditAcct: Bank, Amount: 200, Currency: "USD"}); err != nil {
+		t.Fatalf("PostTransaction: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Simulate a crash between SaveSnapshot and wal.Reset: re-append the
+	// already-compacted posting at its original sequence number, as if
+	// the truncation that should have removed it never happened.
+	entry, err := json.Marshal(struct {
+		Seq   uint64  `json:"seq"`
+		Value Posting `json:"value"`
+	}{Seq: 1, Value: Posting{DebitAcct: Wallet, CreditAcct: Bank, Amount: 200, Currency: "USD"}})
+	if err != nil {
+		t.Fatalf("marshal stale wal entry: %v", err)
+	}
+	walPath := dir + "/postings.jsonl"
+	f, err := os.OpenFile(walPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open wal: %v", err)
+	}
+	if _, err := f.Write(append(entry, '\n')); err != nil {
+		t.Fatalf("append stale wal entry: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("close wal: %v", err)
+	}
+
+	reopened, err := NewFileBook(dir, 1, seed...)
+	if err != nil {
+		t.Fatalf("reopen NewFileBook: %v", err)
+	}
+	defer reopened.Close()
+
+	wallet, _ := reopened.Account(Wallet)
+	bank, _ := reopened.Account(Bank)
+	if wallet.Balance != 800 || bank.Balance != 200 {
+		t.Errorf("expected the stale re-replayed entry to be skipped (wallet=800 bank=200), got wallet=%d bank=%d", wallet.Balance, bank.Balance)
+	}
+}
+
+func TestFileBookReplaysAccountsCreatedAfterLastSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	seed := []Account{
+		{ID: Wallet, Owner: "customer", Type: Asset, Balance: 1000},
+		{ID: Bank, Owner: "customer", Type: Asset, Balance: 0},
+		{ID: External, Owner: "system", Type: Liability, Balance: 0},
+	}
+
+	// snapshotEvery: 100 means neither posting below triggers a
+	// compaction, so wallet:alice is known to this Book only through the
+	// account creations log, never through a snapshot.
+	b, err := NewFileBook(dir, 100, seed...)
+	if err != nil {
+		t.Fatalf("NewFileBook: %v", err)
+	}
+	if _, err := b.EnsureAccount("wallet:alice", "alice", Asset); err != nil {
+		t.Fatalf("EnsureAccount: %v", err)
+	}
+	if _, err := b.PostTransaction(ctx, Posting{DebitAcct: External, CreditAcct: "wallet:alice", Amount: 50, Currency: "USD"}); err != nil {
+		t.Fatalf("PostTransaction: %v", err)
+	}
+	if _, err := b.PostTransaction(ctx, Posting{DebitAcct: "wallet:alice", CreditAcct: Bank, Amount: 20, Currency: "USD"}); err != nil {
+		t.Fatalf("PostTransaction: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := NewFileBook(dir, 100, seed...)
+	if err != nil {
+		t.Fatalf("reopen NewFileBook: %v", err)
+	}
+	defer reopened.Close()
+
+	alice, err := reopened.Account("wallet:alice")
+	if err != nil {
+		t.Fatalf("expected wallet:alice to survive the restart, got: %v", err)
+	}
+	if alice.Balance != 30 {
+		t.Errorf("expected replayed wallet:alice=30, got %d", alice.Balance)
+	}
+}
+
+func TestEnsureAccountCreatesOnceThenReturnsExisting(t *testing.T) {
+	b := testBook()
+	ctx := context.Background()
+
+	a, err := b.EnsureAccount("wallet:alice", "alice", Asset)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.Balance != 0 {
+		t.Fatalf("expected a fresh account to start at 0, got %d", a.Balance)
+	}
+
+	if _, err := b.PostTransaction(ctx, Posting{DebitAcct: External, CreditAcct: "wallet:alice", Amount: 50, Currency: "USD"}); err != nil {
+		t.Fatalf("PostTransaction: %v", err)
+	}
+
+	again, err := b.EnsureAccount("wallet:alice", "alice", Asset)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again.Balance != 50 {
+		t.Errorf("expected EnsureAccount to return the existing account unchanged, got balance %d", again.Balance)
+	}
+}
+
+func TestAccountsListedSorted(t *testing.T) {
+	b := testBook()
+	accs := b.Accounts()
+	if len(accs) != 3 {
+		t.Fatalf("expected 3 accounts, got %d", len(accs))
+	}
+	for i := 1; i < len(accs); i++ {
+		if accs[i-1].ID > accs[i].ID {
+			t.Errorf("accounts not sorted: %s before %s", accs[i-1].ID, accs[i].ID)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package accounts
+
+import "time"
+
+// Posting is one leg of a transaction: Amount moves from DebitAcct to
+// CreditAcct. Memo carries an optional human-readable note (e.g. from the
+// /transfer endpoint) through to the audit trail.
+type Posting struct {
+	TxID       string    `json:"tx_id"`
+	DebitAcct  string    `json:"debit_acct"`
+	CreditAcct string    `json:"credit_acct"`
+	Amount     int64     `json:"amount"`
+	Currency   string    `json:"currency"`
+	Memo       string    `json:"memo,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
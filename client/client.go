@@ -0,0 +1,91 @@
+// Package client is a small typed HTTP client for the bank service, so
+// callers never have to string-match response bodies or guess status
+// codes.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/protocol"
+)
+
+// Error is returned when the server responds with protocol.StatusError.
+// Callers can compare Code against the protocol.Code* constants.
+type Error struct {
+	Code    protocol.Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Client calls a bank service at BaseURL (e.g. "http://localhost:9097").
+// If Token is set, it is sent as an `Authorization: Bearer <Token>`
+// header on every request, e.g. a token minted with
+// middleware.SignToken for a server that requires one.
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// New returns a Client using http.DefaultClient.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: baseURL, HTTPClient: http.DefaultClient}
+}
+
+// Pay posts a payment of amount in currency and returns the resulting
+// balances.
+func (c *Client) Pay(ctx context.Context, amount int64, currency, memo string) (*protocol.Response, error) {
+	return c.post(ctx, "/pay", protocol.Request{Amount: amount, Currency: currency, Memo: memo})
+}
+
+// Save posts a wallet-to-bank transfer of amount in currency and returns
+// the resulting balances.
+func (c *Client) Save(ctx context.Context, amount int64, currency, memo string) (*protocol.Response, error) {
+	return c.post(ctx, "/save", protocol.Request{Amount: amount, Currency: currency, Memo: memo})
+}
+
+func (c *Client) post(ctx context.Context, path string, body any) (*protocol.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("client: encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("client: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("client: do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var out protocol.Response
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("client: decode response: %w", err)
+	}
+
+	if out.Status == protocol.StatusError {
+		return &out, &Error{Code: out.Code, Message: out.Message}
+	}
+	return &out, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
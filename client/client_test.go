@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/middleware"
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/protocol"
+)
+
+func TestPaySuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req protocol.Request
+		json.NewDecoder(r.Body).Decode(&req)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(protocol.Response{
+			Status:    protocol.StatusOK,
+			Balance:   1000 - req.Amount,
+			RequestID: "test-id",
+		})
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	resp, err := c.Pay(context.Background(), 150, "USD", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Balance != 850 {
+		t.Errorf("expected balance 850, got %d", resp.Balance)
+	}
+}
+
+func TestPayInsufficientFunds(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusPaymentRequired)
+		json.NewEncoder(w).Encode(protocol.Response{
+			Status:  protocol.StatusError,
+			Code:    protocol.CodeInsufficientFunds,
+			Message: "accounts: insufficient funds: account wallet",
+		})
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	_, err := c.Pay(context.Background(), 5000, "USD", "")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	clientErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected *client.Error, got %T", err)
+	}
+	if clientErr.Code != protocol.CodeInsufficientFunds {
+		t.Errorf("expected CodeInsufficientFunds, got %s", clientErr.Code)
+	}
+}
+
+func TestPayWithTokenSatisfiesAuthMiddleware(t *testing.T) {
+	secret := []byte("test-secret")
+
+	handler := middleware.AuthMiddleware(secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req protocol.Request
+		json.NewDecoder(r.Body).Decode(&req)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(protocol.Response{
+			Status:    protocol.StatusOK,
+			Balance:   1000 - req.Amount,
+			RequestID: "test-id",
+		})
+	}))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := &Client{BaseURL: server.URL, Token: middleware.SignToken(secret, "alice")}
+	resp, err := c.Pay(context.Background(), 150, "USD", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Balance != 850 {
+		t.Errorf("expected balance 850, got %d", resp.Balance)
+	}
+}
+
+func TestPayWithoutTokenIsRejectedByAuthMiddleware(t *testing.T) {
+	secret := []byte("test-secret")
+
+	handler := middleware.AuthMiddleware(secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not run without a valid bearer token")
+	}))
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := New(server.URL)
+	if _, err := c.Pay(context.Background(), 150, "USD", ""); err == nil {
+		t.Fatal("expected an error when no token is set against an auth-enforcing server")
+	}
+}
@@ -0,0 +1,97 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DiskStore is a Store backed by one JSON file per key under dir, so
+// cached responses survive a process restart.
+type DiskStore struct {
+	dir string
+	ttl time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewDiskStore returns a DiskStore persisting records under dir for ttl
+// each. dir is created if it does not already exist.
+func NewDiskStore(dir string, ttl time.Duration) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("idempotency: create dir: %w", err)
+	}
+	return &DiskStore{dir: dir, ttl: ttl, locks: make(map[string]*sync.Mutex)}, nil
+}
+
+func (s *DiskStore) Begin(key string) (*Record, func(Record), error) {
+	lock := s.lockFor(key)
+	lock.Lock()
+
+	path := s.path(key)
+	if rec, ok, err := s.read(path); err != nil {
+		lock.Unlock()
+		return nil, nil, err
+	} else if ok {
+		lock.Unlock()
+		return rec, nil, nil
+	}
+
+	return nil, func(rec Record) {
+		defer lock.Unlock()
+		_ = s.write(path, rec)
+	}, nil
+}
+
+func (s *DiskStore) lockFor(key string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[key] = l
+	}
+	return l
+}
+
+func (s *DiskStore) read(path string) (*Record, bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("idempotency: read record: %w", err)
+	}
+
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, false, fmt.Errorf("idempotency: decode record: %w", err)
+	}
+	if s.ttl > 0 && time.Since(rec.Timestamp) > s.ttl {
+		return nil, false, nil
+	}
+	return &rec, true, nil
+}
+
+func (s *DiskStore) write(path string, rec Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("idempotency: encode record: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("idempotency: write record: %w", err)
+	}
+	return nil
+}
+
+func (s *DiskStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
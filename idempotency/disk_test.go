@@ -0,0 +1,65 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiskStorePersistsAndReplays(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewDiskStore(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+
+	rec, finish, err := s.Begin("k1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec != nil {
+		t.Fatalf("expected no cached record on first Begin, got %+v", rec)
+	}
+	finish(Record{Status: 200, Body: []byte("hello"), Timestamp: time.Now()})
+
+	// A fresh DiskStore over the same dir should see the persisted record.
+	reopened, err := NewDiskStore(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("NewDiskStore (reopen): %v", err)
+	}
+	rec, _, err = reopened.Begin("k1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec == nil {
+		t.Fatal("expected cached record after reopen, got nil")
+	}
+	if string(rec.Body) != "hello" {
+		t.Errorf("expected body %q, got %q", "hello", rec.Body)
+	}
+}
+
+func TestDiskStoreExpiresAfterTTL(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewDiskStore(dir, time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+
+	_, finish, _ := s.Begin("k1")
+	finish(Record{Status: 200, Body: []byte("stale"), Timestamp: time.Now()})
+
+	time.Sleep(5 * time.Millisecond)
+
+	rec, finish, err := s.Begin("k1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec != nil {
+		t.Fatalf("expected expired record to be treated as missing, got %+v", rec)
+	}
+	if finish == nil {
+		t.Fatal("expected a finish func for an expired key")
+	}
+}
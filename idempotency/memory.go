@@ -0,0 +1,102 @@
+package idempotency
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entry serializes concurrent Begin calls for one key: the first caller
+// holds mu until it calls finish, so anyone else racing in behind it
+// blocks on mu.Lock and then sees the freshly-stored record instead of
+// running the handler again.
+type entry struct {
+	mu     sync.Mutex
+	record *Record
+	elem   *list.Element
+	// inflight is true from the moment a caller is handed a finish func
+	// (the first caller for a key, or one re-running it after its record
+	// expired) until that finish runs; it is guarded by MemoryStore.mu (not
+	// entry.mu) so evictLocked can check it without risking a deadlock
+	// against a handler that is still holding entry.mu mid-flight.
+	inflight bool
+}
+
+// MemoryStore is an in-memory Store with a bounded size (oldest entries
+// are evicted first, skipping any still in flight) and a TTL after which
+// a stored Record is treated as if it had never been cached.
+type MemoryStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxSize int
+	entries map[string]*entry
+	order   *list.List // front = oldest inserted; pure insertion order, replays don't reorder it
+}
+
+// NewMemoryStore returns a MemoryStore holding at most maxSize keys for
+// ttl each. maxSize <= 0 means unbounded.
+func NewMemoryStore(maxSize int, ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:     ttl,
+		maxSize: maxSize,
+		entries: make(map[string]*entry),
+		order:   list.New(),
+	}
+}
+
+func (s *MemoryStore) Begin(key string) (*Record, func(Record), error) {
+	s.mu.Lock()
+	e, ok := s.entries[key]
+	if !ok {
+		e = &entry{inflight: true}
+		s.entries[key] = e
+		e.elem = s.order.PushBack(key)
+		s.evictLocked()
+	}
+	s.mu.Unlock()
+
+	e.mu.Lock()
+	if e.record != nil && !s.expired(*e.record) {
+		rec := *e.record
+		e.mu.Unlock()
+		return &rec, nil, nil
+	}
+
+	s.mu.Lock()
+	e.inflight = true
+	s.mu.Unlock()
+
+	return nil, func(rec Record) {
+		e.record = &rec
+		e.mu.Unlock()
+
+		s.mu.Lock()
+		e.inflight = false
+		s.mu.Unlock()
+	}, nil
+}
+
+func (s *MemoryStore) expired(rec Record) bool {
+	return s.ttl > 0 && time.Since(rec.Timestamp) > s.ttl
+}
+
+// evictLocked drops the oldest entries once the store is over capacity,
+// skipping any still in flight: a first request's entry.mu stays locked
+// until its finish runs, so dropping its entry from the map here would
+// let a concurrent duplicate for the same key miss the map, create a
+// fresh entry, and run the handler again - exactly the double-debit the
+// Store contract promises callers never see. Callers hold s.mu.
+func (s *MemoryStore) evictLocked() {
+	if s.maxSize <= 0 {
+		return
+	}
+	for elem := s.order.Front(); elem != nil && len(s.entries) > s.maxSize; {
+		next := elem.Next()
+		key := elem.Value.(string)
+		if e := s.entries[key]; e != nil && !e.inflight {
+			s.order.Remove(elem)
+			delete(s.entries, key)
+		}
+		elem = next
+	}
+}
@@ -0,0 +1,111 @@
+package idempotency
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+)
+
+// RequestIDHeader matches the header set by the server's own
+// RequestIDMiddleware; IdempotencyKeyHeader lets a caller supply a key
+// independent of the request ID.
+const (
+	RequestIDHeader      = "X-Request-ID"
+	IdempotencyKeyHeader = "Idempotency-Key"
+	replayHeader         = "X-Idempotent-Replay"
+)
+
+// Middleware makes next idempotent for any request that carries an
+// Idempotency-Key or X-Request-ID header: the first request with a given
+// (token, method, path, body) runs next as normal and its response is
+// cached in store; any request racing in behind it, or arriving later,
+// gets the cached response replayed with X-Idempotent-Replay: true
+// instead of running next again. Requests with neither header pass
+// straight through uncached.
+func Middleware(store Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get(IdempotencyKeyHeader)
+			if token == "" {
+				token = r.Header.Get(RequestIDHeader)
+			}
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "idempotency: failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			rec, finish, err := store.Begin(Key(token, r.Method, r.URL.Path, body))
+			if err != nil {
+				http.Error(w, "idempotency: store error", http.StatusInternalServerError)
+				return
+			}
+			if rec != nil {
+				replay(w, rec)
+				return
+			}
+
+			rw := &bufferingResponseWriter{ResponseWriter: w}
+			// Deferred so a panic from next (the case RecoverMiddleware
+			// exists to survive) still releases the per-key lock Begin
+			// took out; otherwise every later request for this key would
+			// deadlock waiting on it forever.
+			defer func() {
+				finish(Record{
+					Status:    rw.status,
+					Body:      rw.body.Bytes(),
+					Header:    rw.header,
+					Timestamp: time.Now(),
+				})
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, rec *Record) {
+	dst := w.Header()
+	for k, vv := range rec.Header {
+		for _, v := range vv {
+			dst.Add(k, v)
+		}
+	}
+	dst.Set(replayHeader, "true")
+	w.WriteHeader(rec.Status)
+	w.Write(rec.Body)
+}
+
+// bufferingResponseWriter records the status, headers and body a handler
+// wrote so they can be replayed for a later duplicate request.
+type bufferingResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rw *bufferingResponseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+	rw.status = code
+	rw.header = rw.ResponseWriter.Header().Clone()
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *bufferingResponseWriter) Write(p []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(p)
+	return rw.ResponseWriter.Write(p)
+}
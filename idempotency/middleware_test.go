@@ -0,0 +1,152 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMiddlewarePassesThroughWithoutAKey(t *testing.T) {
+	var calls atomic.Int32
+	handler := Middleware(NewMemoryStore(10, time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/pay", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected handler to run for both requests, ran %d times", got)
+	}
+}
+
+func TestMiddlewareReplaysDuplicateRequest(t *testing.T) {
+	var calls atomic.Int32
+	handler := Middleware(NewMemoryStore(10, time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("paid"))
+	}))
+
+	first := httptest.NewRequest(http.MethodPost, "/pay", nil)
+	first.Header.Set(RequestIDHeader, "dup-1")
+	w1 := httptest.NewRecorder()
+	handler.ServeHTTP(w1, first)
+
+	if w1.Header().Get("X-Idempotent-Replay") != "" {
+		t.Error("first request should not be marked as a replay")
+	}
+
+	second := httptest.NewRequest(http.MethodPost, "/pay", nil)
+	second.Header.Set(RequestIDHeader, "dup-1")
+	w2 := httptest.NewRecorder()
+	handler.ServeHTTP(w2, second)
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected handler to run exactly once, ran %d times", got)
+	}
+	if w2.Header().Get("X-Idempotent-Replay") != "true" {
+		t.Error("second request should be marked as a replay")
+	}
+	if w2.Body.String() != "paid" {
+		t.Errorf("expected replayed body %q, got %q", "paid", w2.Body.String())
+	}
+}
+
+func TestMiddlewareConcurrentDuplicatesOnlyRunHandlerOnce(t *testing.T) {
+	var calls atomic.Int32
+	unblock := make(chan struct{})
+	handler := Middleware(NewMemoryStore(10, time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-unblock
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("debited once"))
+	}))
+
+	const goroutines = 10
+	var wg sync.WaitGroup
+	results := make([]*httptest.ResponseRecorder, goroutines)
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/pay", nil)
+			req.Header.Set(RequestIDHeader, "race-key")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			results[i] = w
+		}(i)
+	}
+
+	close(unblock)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected handler to run exactly once across concurrent duplicates, ran %d times", got)
+	}
+	for i, w := range results {
+		if w.Body.String() != "debited once" {
+			t.Errorf("goroutine %d: expected replayed body %q, got %q", i, "debited once", w.Body.String())
+		}
+	}
+}
+
+func TestMiddlewareReleasesLockWhenHandlerPanics(t *testing.T) {
+	handler := Middleware(NewMemoryStore(10, time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	// A real deployment always runs this behind RecoverMiddleware; emulate
+	// that here so the panic doesn't fail the test, and so we can observe
+	// whether the per-key lock came back afterwards.
+	recoverHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() { recover() }()
+		handler.ServeHTTP(w, r)
+	})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/pay", nil)
+		req.Header.Set(RequestIDHeader, "panic-key")
+		w := httptest.NewRecorder()
+
+		done := make(chan struct{})
+		go func() {
+			recoverHandler.ServeHTTP(w, req)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("request %d deadlocked waiting on the per-key lock held by the panicking request", i)
+		}
+	}
+}
+
+func TestMiddlewareDifferentBodiesAreNotConflated(t *testing.T) {
+	var calls atomic.Int32
+	handler := Middleware(NewMemoryStore(10, time.Minute))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, body := range []string{`{"amount":1}`, `{"amount":2}`} {
+		req := httptest.NewRequest(http.MethodPost, "/pay", strings.NewReader(body))
+		req.Header.Set(RequestIDHeader, "same-id-different-body")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected handler to run once per distinct body, ran %d times", got)
+	}
+}
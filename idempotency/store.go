@@ -0,0 +1,42 @@
+// Package idempotency lets HTTP handlers safely replay the response to a
+// request they have already processed, keyed by the caller-supplied
+// X-Request-ID (or Idempotency-Key) header together with the method, path
+// and body of the request.
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Record is the cached outcome of a previously handled request.
+type Record struct {
+	Status    int
+	Body      []byte
+	Header    http.Header
+	Timestamp time.Time
+}
+
+// Store caches Records keyed by a caller-chosen string (see Key) and makes
+// concurrent duplicate requests for the same key wait for each other
+// instead of running the underlying handler more than once.
+//
+// Begin looks up key. If a non-expired Record is already stored, it is
+// returned immediately and finish is nil. Otherwise Begin blocks until no
+// other caller is mid-flight for key, then returns a nil Record and a
+// finish func: the caller must run the handler and call finish exactly
+// once with the resulting Record, which both stores it for future lookups
+// and releases any callers waiting behind it.
+type Store interface {
+	Begin(key string) (rec *Record, finish func(Record), err error)
+}
+
+// Key builds the cache key for a request from its caller-supplied
+// idempotency token, method, path and raw body.
+func Key(token, method, path string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf("%s:%s:%s:%s", token, method, path, hex.EncodeToString(sum[:]))
+}
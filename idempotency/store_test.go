@@ -0,0 +1,142 @@
+package idempotency
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreReplaysCachedRecord(t *testing.T) {
+	s := NewMemoryStore(10, time.Minute)
+
+	rec, finish, err := s.Begin("k1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec != nil {
+		t.Fatalf("expected no cached record on first Begin, got %+v", rec)
+	}
+	finish(Record{Status: 200, Body: []byte("hello"), Timestamp: time.Now()})
+
+	rec, finish, err = s.Begin("k1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec == nil {
+		t.Fatal("expected cached record on second Begin, got nil")
+	}
+	if finish != nil {
+		t.Fatal("expected no finish func for a cache hit")
+	}
+	if string(rec.Body) != "hello" {
+		t.Errorf("expected body %q, got %q", "hello", rec.Body)
+	}
+}
+
+func TestMemoryStoreConcurrentBeginOnlyRunsOnce(t *testing.T) {
+	s := NewMemoryStore(10, time.Minute)
+
+	const goroutines = 50
+	var ran atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rec, finish, err := s.Begin("shared-key")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			if finish == nil {
+				if rec == nil {
+					t.Error("expected either a record or a finish func")
+				}
+				return
+			}
+
+			ran.Add(1)
+			finish(Record{Status: 200, Body: []byte("done"), Timestamp: time.Now()})
+		}()
+	}
+
+	wg.Wait()
+
+	if got := ran.Load(); got != 1 {
+		t.Errorf("expected the handler to run exactly once, ran %d times", got)
+	}
+}
+
+func TestMemoryStoreExpiresAfterTTL(t *testing.T) {
+	s := NewMemoryStore(10, time.Millisecond)
+
+	_, finish, _ := s.Begin("k1")
+	finish(Record{Status: 200, Body: []byte("stale"), Timestamp: time.Now()})
+
+	time.Sleep(5 * time.Millisecond)
+
+	rec, finish, _ := s.Begin("k1")
+	if rec != nil {
+		t.Fatalf("expected expired record to be treated as missing, got %+v", rec)
+	}
+	if finish == nil {
+		t.Fatal("expected a finish func for an expired key")
+	}
+}
+
+func TestMemoryStoreEvictsOldestOverCapacity(t *testing.T) {
+	s := NewMemoryStore(2, time.Minute)
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, finish, _ := s.Begin(key)
+		finish(Record{Status: 200, Body: []byte(key), Timestamp: time.Now()})
+	}
+
+	if _, ok := s.entries["a"]; ok {
+		t.Error("expected oldest key \"a\" to have been evicted")
+	}
+	if _, ok := s.entries["c"]; !ok {
+		t.Error("expected most recent key \"c\" to still be present")
+	}
+}
+
+func TestMemoryStoreNeverEvictsAnInFlightEntry(t *testing.T) {
+	s := NewMemoryStore(2, time.Minute)
+
+	// "a" never calls finish, so it stays in flight through everything
+	// below - eviction must skip it rather than drop it from the map out
+	// from under the caller still holding its lock.
+	if _, _, err := s.Begin("a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, key := range []string{"b", "c"} {
+		_, finish, _ := s.Begin(key)
+		finish(Record{Status: 200, Body: []byte(key), Timestamp: time.Now()})
+	}
+
+	if _, ok := s.entries["a"]; !ok {
+		t.Error("expected the in-flight key \"a\" not to have been evicted")
+	}
+
+	// A concurrent duplicate for "a" must still find the in-flight entry
+	// and block behind it rather than missing the map and running the
+	// handler a second time.
+	done := make(chan struct{})
+	go func() {
+		_, finish, _ := s.Begin("a")
+		if finish != nil {
+			t.Error("expected the duplicate Begin for \"a\" to see the in-flight entry, not start a new one")
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Error("expected the duplicate Begin for \"a\" to block until finish runs")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,27 @@
+// Package logging lets handlers retrieve a structured logger that has
+// already been populated with the current request's identifying fields
+// (request ID, trace ID, span ID) by middleware further up the chain,
+// instead of passing a logger or those fields around explicitly.
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying logger, retrievable with
+// FromContext.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the logger NewContext attached to ctx, or
+// slog.Default() if none was attached.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
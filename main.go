@@ -1,58 +1,141 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
-	"strconv"
-	"sync"
-	"sync/atomic"
+	"os"
+	"strings"
 	"time"
+
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/accounts"
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/idempotency"
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/logging"
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/middleware"
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/protocol"
 )
 
-var mtx sync.Mutex
-var money = atomic.Int64{}
-var bank = atomic.Int64{}
+// snapshotEvery controls how many postings accumulate before the book
+// compacts them into an account snapshot.
+const snapshotEvery = 100
 
-func main() {
-	money.Add(1000)
+// idempotencyTTL is how long a cached /pay or /save response can be
+// replayed for a repeated request before it is treated as new again.
+const idempotencyTTL = 10 * time.Minute
+
+// rateLimitRPS and rateLimitBurst bound how many requests per second
+// (and how large a burst) a single caller (or IP, if unauthenticated)
+// may make across any route.
+const (
+	rateLimitRPS   = 20
+	rateLimitBurst = 40
+)
 
-	payHandlerChain := RequestIDMiddleware(
-		LoggingMiddleware(
-			http.HandlerFunc(payHandler),
-		),
+var book *accounts.Book
+var idemStore = idempotency.NewMemoryStore(10000, idempotencyTTL)
+
+// baseLogger is the root structured logger every request's logger
+// (retrieved via logging.FromContext) is derived from.
+var baseLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+func main() {
+	// accounts.Wallet and accounts.Bank are seeded here for parity with
+	// the pre-auth accounts walletAccount/bankAccount fall back to when
+	// no caller is in context (and with the unit tests, which exercise
+	// handlers that way); every mounted route below that touches an
+	// account requires middleware.AuthMiddleware, so production traffic always resolves
+	// to a caller-scoped "wallet:<caller>"/"bank:<caller>" pair instead
+	// and never reaches these two. A new caller's own wallet is
+	// provisioned at a zero balance on first use (see EnsureAccount in
+	// payHandler/saveHandler) and must be funded before /pay or /save
+	// will do anything: have an AdminCaller-signed token post a
+	// /transfer from accounts.External (a liability account, so it may
+	// go negative) to "wallet:<caller>".
+	b, err := accounts.NewFileBook("data", snapshotEvery,
+		accounts.Account{ID: accounts.Wallet, Owner: "customer", Type: accounts.Asset, Balance: 1000},
+		accounts.Account{ID: accounts.Bank, Owner: "customer", Type: accounts.Asset, Balance: 0},
+		accounts.Account{ID: accounts.External, Owner: "system", Type: accounts.Liability, Balance: 0},
 	)
-	saveHandlerChain := RequestIDMiddleware(
-		LoggingMiddleware(
-			http.HandlerFunc(saveHandler),
-		),
+	if err != nil {
+		fmt.Println("failed to open book:", err.Error())
+		return
+	}
+	book = b
+
+	authSecret := loadAuthSecret()
+	limiter := middleware.NewRateLimiter(rateLimitRPS, rateLimitBurst)
+
+	base := middleware.Chain(
+		RequestIDMiddleware(baseLogger),
+		middleware.RecoverMiddleware(),
+		LoggingMiddleware,
 	)
+	rateLimit := middleware.RateLimitMiddleware(limiter)
+
+	mux := http.NewServeMux()
+	routes := middleware.NewRegistry(mux, base)
 
-	http.Handle("/pay", payHandlerChain)
-	http.Handle("/save", saveHandlerChain)
+	// rateLimit runs after AuthMiddleware on every authenticated route
+	// (and alone on /healthz, which has no caller to key off and falls
+	// back to remote IP) so it keys per-caller traffic by the token rather than by
+	// whatever IP or proxy the caller happens to share with others.
+	routes.HandleFunc("/pay", payHandler, middleware.AuthMiddleware(authSecret), rateLimit, idempotency.Middleware(idemStore))
+	routes.HandleFunc("/save", saveHandler, middleware.AuthMiddleware(authSecret), rateLimit, idempotency.Middleware(idemStore))
+	routes.HandleFunc("/transfer", transferHandler, middleware.AuthMiddleware(authSecret), rateLimit)
+	routes.HandleFunc("/accounts", accountsHandler, middleware.AuthMiddleware(authSecret), rateLimit)
+	routes.HandleFunc("/accounts/", accountHandler, middleware.AuthMiddleware(authSecret), rateLimit)
+	routes.HandleFunc("/healthz", healthzHandler, rateLimit)
 
 	fmt.Println("Server starting on port 9097...")
-	err := http.ListenAndServe(":9097", nil)
-	if err != nil {
+	if err := http.ListenAndServe(":9097", mux); err != nil {
 		fmt.Println("HTTP server error", err.Error())
 	}
 }
 
+// loadAuthSecret reads the HMAC secret AuthMiddleware signs/verifies
+// bearer tokens with from AUTH_SECRET, or generates an ephemeral one for
+// this process if it isn't set.
+func loadAuthSecret() []byte {
+	if s := os.Getenv("AUTH_SECRET"); s != "" {
+		return []byte(s)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic("failed to generate auth secret: " + err.Error())
+	}
+	fmt.Println("AUTH_SECRET not set; generated an ephemeral secret for this process")
+	return secret
+}
+
+// LoggingMiddleware logs a start and end record for every request as
+// structured JSON, using the logger RequestIDMiddleware already
+// populated with request/trace fields in the request's context.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		requestID := GetRequestID(r.Context())
+		logger := logging.FromContext(r.Context())
 
-		fmt.Printf("[%s] Start %s %s\n", requestID, r.Method, r.URL.Path)
+		logger.Info("request started",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote_addr", r.RemoteAddr,
+		)
 
 		rw := &responseWriter{ResponseWriter: w, status: 200}
 
 		next.ServeHTTP(rw, r)
 
-		duration := time.Since(start)
-
-		fmt.Printf("[%s] End %s %s - status: %d, duration: %v\n",
-			requestID, r.Method, r.URL.Path, rw.status, duration)
+		logger.Info("request finished",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote_addr", r.RemoteAddr,
+			"status", rw.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
 	})
 }
 
@@ -66,85 +149,302 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// decodeRequest reads and validates a protocol.Request. On failure it
+// writes the INVALID_ARGUMENTS error response itself, so callers can just
+// return.
+func decodeRequest(w http.ResponseWriter, r *http.Request, requestID string) (protocol.Request, bool) {
+	logger := logging.FromContext(r.Context())
+
+	var req protocol.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Error("request parse failed", "error", err)
+		writeError(w, requestID, protocol.CodeInvalidArguments, "invalid request body")
+		return req, false
+	}
+
+	if req.Amount <= 0 {
+		logger.Error("invalid amount", "amount", req.Amount)
+		writeError(w, requestID, protocol.CodeInvalidArguments, "amount must be positive")
+		return req, false
+	}
+
+	if req.Currency == "" {
+		req.Currency = "USD"
+	}
+
+	return req, true
+}
+
+// walletAccount and bankAccount resolve the wallet/bank account IDs a
+// request should debit or credit: the caller's own accounts if
+// middleware.AuthMiddleware put an identity in context, otherwise the
+// shared default accounts used when auth isn't in front of a route.
+func walletAccount(r *http.Request) string {
+	if caller, ok := middleware.CallerFromContext(r.Context()); ok {
+		return "wallet:" + caller
+	}
+	return accounts.Wallet
+}
+
+func bankAccount(r *http.Request) string {
+	if caller, ok := middleware.CallerFromContext(r.Context()); ok {
+		return "bank:" + caller
+	}
+	return accounts.Bank
+}
+
+func callerOwner(r *http.Request) string {
+	if caller, ok := middleware.CallerFromContext(r.Context()); ok {
+		return caller
+	}
+	return "customer"
+}
+
+// ownsAccount reports whether id is one of caller's own wallet/bank
+// accounts, as resolved by walletAccount/bankAccount.
+func ownsAccount(caller, id string) bool {
+	return id == "wallet:"+caller || id == "bank:"+caller
+}
+
+// payHandler is a thin wrapper that posts a wallet -> external debit
+// against the caller's accounts.
 func payHandler(w http.ResponseWriter, r *http.Request) {
 	requestID := GetRequestID(r.Context())
 
-	reqBody, err := io.ReadAll(r.Body)
-	if err != nil {
-		msg := "error read HTTP body" + err.Error()
-		fmt.Printf("[%s] %s\n", requestID, msg)
-		w.Write([]byte(msg))
+	req, ok := decodeRequest(w, r, requestID)
+	if !ok {
+		return
+	}
+
+	wallet := walletAccount(r)
+	if _, err := book.EnsureAccount(wallet, callerOwner(r), accounts.Asset); err != nil {
+		writePostingError(w, r, requestID, err)
 		return
 	}
 
-	reqBodyString := string(reqBody)
-	reqBodyInt, err := strconv.Atoi(reqBodyString)
+	txID, err := book.PostTransaction(r.Context(), accounts.Posting{
+		DebitAcct:  wallet,
+		CreditAcct: accounts.External,
+		Amount:     req.Amount,
+		Currency:   req.Currency,
+		Memo:       req.Memo,
+	})
 	if err != nil {
-		fmt.Printf("[%s] Parse error: %v\n", requestID, err)
-		w.Write([]byte("invalid amount"))
+		writePostingError(w, r, requestID, err)
 		return
 	}
 
-	mtx.Lock()
-	defer mtx.Unlock()
+	newBalance, _ := book.Account(wallet)
+	logging.FromContext(r.Context()).Info("payment successful",
+		"amount", req.Amount,
+		"balance_after", newBalance.Balance,
+	)
+	writeBalances(w, requestID, txID, wallet, bankAccount(r))
+}
+
+// saveHandler is a thin wrapper that posts a wallet -> bank transfer
+// against the caller's accounts.
+func saveHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := GetRequestID(r.Context())
+
+	req, ok := decodeRequest(w, r, requestID)
+	if !ok {
+		return
+	}
 
-	if money.Load() >= int64(reqBodyInt) {
-		money.Add(int64(-reqBodyInt))
+	wallet := walletAccount(r)
+	bank := bankAccount(r)
+	owner := callerOwner(r)
+	if _, err := book.EnsureAccount(wallet, owner, accounts.Asset); err != nil {
+		writePostingError(w, r, requestID, err)
+		return
+	}
+	if _, err := book.EnsureAccount(bank, owner, accounts.Asset); err != nil {
+		writePostingError(w, r, requestID, err)
+		return
+	}
 
-		fmt.Printf("[%s] Payment successful: %d, new balance: %d\n",
-			requestID, reqBodyInt, money.Load())
+	txID, err := book.PostTransaction(r.Context(), accounts.Posting{
+		DebitAcct:  wallet,
+		CreditAcct: bank,
+		Amount:     req.Amount,
+		Currency:   req.Currency,
+		Memo:       req.Memo,
+	})
+	if err != nil {
+		writePostingError(w, r, requestID, err)
+		return
+	}
 
-		valueMoney := strconv.Itoa(int(money.Load()))
-		valuebank := strconv.Itoa(int(bank.Load()))
+	newBalance, _ := book.Account(wallet)
+	logging.FromContext(r.Context()).Info("save successful",
+		"amount", req.Amount,
+		"balance_after", newBalance.Balance,
+	)
+	writeBalances(w, requestID, txID, wallet, bank)
+}
 
-		response := fmt.Sprintf("current balance: %s, current bank: %s",
-			valueMoney, valuebank)
-		w.Write([]byte(response))
-	} else {
-		fmt.Printf("[%s] Low balance: tried %d, have %d\n",
-			requestID, reqBodyInt, money.Load())
-		w.Write([]byte("low balance"))
+// writePostingError maps a PostTransaction error to the right protocol
+// code and HTTP status.
+func writePostingError(w http.ResponseWriter, r *http.Request, requestID string, err error) {
+	code := protocol.CodeServerError
+	if errors.Is(err, accounts.ErrOverdrawn) {
+		code = protocol.CodeInsufficientFunds
 	}
+	logging.FromContext(r.Context()).Error("transaction failed", "error", err)
+	writeError(w, requestID, code, err.Error())
 }
 
-func saveHandler(w http.ResponseWriter, r *http.Request) {
+// writeBalances writes a successful protocol.Response carrying the
+// current balances of the given wallet/bank accounts.
+func writeBalances(w http.ResponseWriter, requestID, txID, walletID, bankID string) {
+	wallet, _ := book.Account(walletID)
+	bank, _ := book.Account(bankID)
+
+	writeJSON(w, http.StatusOK, protocol.Response{
+		Status:    protocol.StatusOK,
+		Balance:   wallet.Balance,
+		Bank:      bank.Balance,
+		RequestID: requestID,
+		TxID:      txID,
+	})
+}
+
+// writeError writes a protocol.Response describing a failure, at the
+// HTTP status the code maps to.
+func writeError(w http.ResponseWriter, requestID string, code protocol.Code, message string) {
+	writeJSON(w, code.HTTPStatus(), protocol.Response{
+		Status:    protocol.StatusError,
+		Code:      code,
+		Message:   message,
+		RequestID: requestID,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// transferRequest is the body accepted by /transfer.
+type transferRequest struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount int64  `json:"amount"`
+	Memo   string `json:"memo"`
+}
+
+// transferHandler posts an account-to-account transfer. The caller must
+// own the debited (From) account, unless it is middleware.AdminCaller,
+// which may move funds out of any account - including accounts.External,
+// the usual way to fund a caller's wallet the first time (see main).
+func transferHandler(w http.ResponseWriter, r *http.Request) {
 	requestID := GetRequestID(r.Context())
 
-	reqBody, err := io.ReadAll(r.Body)
-	if err != nil {
-		msg := "error read HTTP body" + err.Error()
-		fmt.Printf("[%s] %s\n", requestID, msg)
-		w.Write([]byte(msg))
+	var req transferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logging.FromContext(r.Context()).Error("request parse failed", "error", err)
+		writeError(w, requestID, protocol.CodeInvalidArguments, "invalid request body")
+		return
+	}
+	if req.Amount <= 0 {
+		writeError(w, requestID, protocol.CodeInvalidArguments, "amount must be positive")
+		return
+	}
+
+	caller, _ := middleware.CallerFromContext(r.Context())
+	if !middleware.IsAdmin(r.Context()) && !ownsAccount(caller, req.From) {
+		logging.FromContext(r.Context()).Error("transfer forbidden", "caller", caller, "from", req.From)
+		writeError(w, requestID, protocol.CodeForbidden, "caller does not own the debited account")
 		return
 	}
 
-	reqBodyString := string(reqBody)
-	reqBodyInt, err := strconv.Atoi(reqBodyString)
+	txID, err := book.PostTransaction(r.Context(), accounts.Posting{
+		DebitAcct:  req.From,
+		CreditAcct: req.To,
+		Amount:     req.Amount,
+		Currency:   "USD",
+		Memo:       req.Memo,
+	})
 	if err != nil {
-		fmt.Printf("[%s] Parse error: %v\n", requestID, err)
-		w.Write([]byte("invalid amount"))
+		code := protocol.CodeInvalidArguments
+		if errors.Is(err, accounts.ErrOverdrawn) {
+			code = protocol.CodeInsufficientFunds
+		} else if errors.Is(err, accounts.ErrAccountNotFound) {
+			code = protocol.CodeInvalidArguments
+		}
+		logging.FromContext(r.Context()).Error("transfer failed", "error", err, "from", req.From, "to", req.To)
+		writeError(w, requestID, code, err.Error())
 		return
 	}
 
-	mtx.Lock()
-	defer mtx.Unlock()
+	logging.FromContext(r.Context()).Info("transfer posted",
+		"from", req.From,
+		"to", req.To,
+		"amount", req.Amount,
+		"tx_id", txID,
+	)
 
-	if money.Load() >= int64(reqBodyInt) {
-		money.Add(int64(-reqBodyInt))
-		bank.Add(int64(reqBodyInt))
+	writeJSON(w, http.StatusOK, protocol.Response{
+		Status:    protocol.StatusOK,
+		RequestID: requestID,
+		TxID:      txID,
+	})
+}
 
-		fmt.Printf("[%s] Transfer successful: %d, new balance: %d, bank: %d\n",
-			requestID, reqBodyInt, money.Load(), bank.Load())
+// accountsHandler lists every account middleware.AdminCaller is allowed
+// to see, or just the caller's own accounts otherwise.
+func accountsHandler(w http.ResponseWriter, r *http.Request) {
+	all := book.Accounts()
 
-		valueMoney := strconv.Itoa(int(money.Load()))
-		valuebank := strconv.Itoa(int(bank.Load()))
+	if middleware.IsAdmin(r.Context()) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(all)
+		return
+	}
 
-		response := fmt.Sprintf("current balance: %s, current bank: %s",
-			valueMoney, valuebank)
-		w.Write([]byte(response))
-	} else {
-		fmt.Printf("[%s] Low balance for transfer: tried %d, have %d\n",
-			requestID, reqBodyInt, money.Load())
-		w.Write([]byte("low balance for bank transfer"))
+	caller, _ := middleware.CallerFromContext(r.Context())
+	owned := make([]accounts.Account, 0, len(all))
+	for _, a := range all {
+		if a.Owner == caller {
+			owned = append(owned, a)
+		}
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(owned)
+}
+
+// accountHandler returns a single account by ID, e.g. /accounts/wallet,
+// restricted to accounts owned by the caller unless it is
+// middleware.AdminCaller.
+func accountHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/accounts/")
+	if id == "" {
+		http.Error(w, "missing account id", http.StatusBadRequest)
+		return
+	}
+
+	account, err := book.Account(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	caller, _ := middleware.CallerFromContext(r.Context())
+	if !middleware.IsAdmin(r.Context()) && account.Owner != caller {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+// healthzHandler reports the sequence number of the last posting that
+// has been durably applied, so operators can tell when startup replay has
+// finished and the book is caught up.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "ok, wal_offset=%d", book.WALOffset())
 }
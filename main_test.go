@@ -3,18 +3,39 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/accounts"
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/middleware"
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/protocol"
 )
 
+// newTestBook returns an in-memory Book seeded exactly like the default
+// accounts main() creates, for use as a fresh fixture per test case.
+func newTestBook() *accounts.Book {
+	return accounts.NewMemoryBook(
+		accounts.Account{ID: accounts.Wallet, Owner: "customer", Type: accounts.Asset, Balance: 1000},
+		accounts.Account{ID: accounts.Bank, Owner: "customer", Type: accounts.Asset, Balance: 0},
+		accounts.Account{ID: accounts.External, Owner: "system", Type: accounts.Liability, Balance: 0},
+	)
+}
+
+func balances(b *accounts.Book) (money, bank int64) {
+	wallet, _ := b.Account(accounts.Wallet)
+	bankAcct, _ := b.Account(accounts.Bank)
+	return wallet.Balance, bankAcct.Balance
+}
+
 func TestRequestIDMiddleware(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -49,7 +70,7 @@ func TestRequestIDMiddleware(t *testing.T) {
 				}
 			})
 
-			handler := RequestIDMiddleware(testHandler)
+			handler := RequestIDMiddleware(testLogger())(testHandler)
 			req := httptest.NewRequest("GET", "/test", nil)
 			if tt.headerValue != "" {
 				req.Header.Set(RequestIDHeader, tt.headerValue)
@@ -61,15 +82,32 @@ func TestRequestIDMiddleware(t *testing.T) {
 	}
 }
 
-func TestLoggingMiddleware(t *testing.T) {
-	oldOutput := logOutput
-	defer func() { logOutput = oldOutput }()
+// testLogger returns a slog.Logger that discards its output, for tests
+// that only care about the handler under test, not what gets logged.
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(io.Discard, nil))
+}
 
-	var logMessages []string
-	logOutput = func(format string, args ...interface{}) {
-		msg := fmt.Sprintf(format, args...)
-		logMessages = append(logMessages, msg)
+// decodeLogLines parses each line of buf as a JSON log record.
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("log line is not valid JSON: %v\nline: %s", err, line)
+		}
+		records = append(records, rec)
 	}
+	return records
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
 
 	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(5 * time.Millisecond)
@@ -77,89 +115,134 @@ func TestLoggingMiddleware(t *testing.T) {
 		w.Write([]byte("OK"))
 	})
 
-	handler := RequestIDMiddleware(LoggingMiddleware(testHandler))
+	handler := RequestIDMiddleware(logger)(LoggingMiddleware(testHandler))
 	req := httptest.NewRequest("POST", "/test", nil)
 	w := httptest.NewRecorder()
 
 	handler.ServeHTTP(w, req)
 
-	if len(logMessages) < 2 {
-		t.Fatalf("Expected at least 2 log messages, got %d", len(logMessages))
+	records := decodeLogLines(t, &buf)
+	if len(records) < 2 {
+		t.Fatalf("expected at least 2 JSON log records, got %d", len(records))
+	}
+	start, end := records[0], records[1]
+
+	for _, field := range []string{"request_id", "trace_id", "span_id"} {
+		if start[field] == "" || start[field] == nil {
+			t.Errorf("start record missing %s: %+v", field, start)
+		}
 	}
 
-	startLog := logMessages[0]
-	endLog := logMessages[1]
+	if start["method"] != "POST" || start["path"] != "/test" {
+		t.Errorf("start record missing method/path: %+v", start)
+	}
+	if end["method"] != "POST" || end["path"] != "/test" {
+		t.Errorf("end record missing method/path: %+v", end)
+	}
+	if end["status"] != float64(http.StatusOK) {
+		t.Errorf("end record missing status 200: %+v", end)
+	}
+	if _, ok := end["duration_ms"]; !ok {
+		t.Errorf("end record missing duration_ms: %+v", end)
+	}
 
-	if !strings.Contains(startLog, "Start POST /test") {
-		t.Errorf("Start log missing method/path: %s", startLog)
+	traceparent := w.Header().Get(TraceParentHeader)
+	if traceparent == "" {
+		t.Fatal("expected a traceparent response header")
 	}
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || parts[1] != start["trace_id"] {
+		t.Errorf("response traceparent %q does not carry the logged trace_id %v", traceparent, start["trace_id"])
+	}
+}
+
+func TestRequestIDMiddlewarePropagatesIncomingTraceparent(t *testing.T) {
+	handler := RequestIDMiddleware(testLogger())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc, ok := GetTraceContext(r.Context())
+		if !ok {
+			t.Fatal("expected a trace context in the request")
+		}
+		if tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("expected incoming trace ID to be preserved, got %s", tc.TraceID)
+		}
+	}))
 
-	if !strings.Contains(endLog, "End POST /test") {
-		t.Errorf("End log missing method/path: %s", endLog)
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	traceparent := w.Header().Get(TraceParentHeader)
+	if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+		t.Errorf("expected response traceparent to carry the incoming trace ID, got %s", traceparent)
 	}
+}
 
-	if !strings.Contains(endLog, "status: 200") {
-		t.Errorf("End log missing status: %s", endLog)
+// jsonBody marshals v into a request body, panicking on failure since
+// every call site passes a literal struct.
+func jsonBody(v any) *bytes.Buffer {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
 	}
+	return bytes.NewBuffer(data)
+}
 
-	if !strings.Contains(endLog, "duration:") {
-		t.Errorf("End log missing duration: %s", endLog)
+func decodeResponse(t *testing.T, resp *http.Response) protocol.Response {
+	t.Helper()
+	var out protocol.Response
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode response: %v", err)
 	}
+	return out
 }
 
 func TestPayHandler(t *testing.T) {
-	defer func() { money.Store(1000) }()
-	money.Store(1000)
-	bank.Store(0)
+	defer func() { book = newTestBook() }()
 
 	tests := []struct {
-		name           string
-		requestBody    string
-		expectedStatus int
-		expectedBody   string
-		expectedMoney  int64
-		expectedBank   int64
+		name            string
+		requestBody     any
+		expectedStatus  int
+		expectedStatStr protocol.Status
+		expectedCode    protocol.Code
+		expectedMoney   int64
+		expectedBank    int64
 	}{
 		{
-			name:           "Successful Payment",
-			requestBody:    "150",
-			expectedStatus: 200,
-			expectedBody:   "current balance: 850, current bank: 0",
-			expectedMoney:  850,
-			expectedBank:   0,
-		},
-		{
-			name:           "Insufficient Funds",
-			requestBody:    "1500",
-			expectedStatus: 200,
-			expectedBody:   "low balance",
-			expectedMoney:  1000,
-			expectedBank:   0,
+			name:            "Successful Payment",
+			requestBody:     protocol.Request{Amount: 150, Currency: "USD"},
+			expectedStatus:  http.StatusOK,
+			expectedStatStr: protocol.StatusOK,
+			expectedMoney:   850,
+			expectedBank:    0,
 		},
 		{
-			name:           "Invalid Amount Format",
-			requestBody:    "not-a-number",
-			expectedStatus: 200,
-			expectedBody:   "invalid amount",
-			expectedMoney:  1000,
-			expectedBank:   0,
+			name:            "Insufficient Funds",
+			requestBody:     protocol.Request{Amount: 1500, Currency: "USD"},
+			expectedStatus:  http.StatusPaymentRequired,
+			expectedStatStr: protocol.StatusError,
+			expectedCode:    protocol.CodeInsufficientFunds,
+			expectedMoney:   1000,
+			expectedBank:    0,
 		},
 		{
-			name:           "Empty Request Body",
-			requestBody:    "",
-			expectedStatus: 200,
-			expectedBody:   "invalid amount",
-			expectedMoney:  1000,
-			expectedBank:   0,
+			name:            "Invalid Amount",
+			requestBody:     protocol.Request{Amount: 0, Currency: "USD"},
+			expectedStatus:  http.StatusBadRequest,
+			expectedStatStr: protocol.StatusError,
+			expectedCode:    protocol.CodeInvalidArguments,
+			expectedMoney:   1000,
+			expectedBank:    0,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			money.Store(1000)
-			bank.Store(0)
+			book = newTestBook()
 
-			req := httptest.NewRequest("POST", "/pay", bytes.NewBufferString(tt.requestBody))
+			req := httptest.NewRequest("POST", "/pay", jsonBody(tt.requestBody))
 			req = req.WithContext(context.WithValue(req.Context(), RequestIDContextKey, "test-id"))
 
 			w := httptest.NewRecorder()
@@ -168,65 +251,70 @@ func TestPayHandler(t *testing.T) {
 			resp := w.Result()
 			defer resp.Body.Close()
 
-			body, _ := io.ReadAll(resp.Body)
-			bodyStr := strings.TrimSpace(string(body))
-
 			if resp.StatusCode != tt.expectedStatus {
 				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
 			}
 
-			if bodyStr != tt.expectedBody {
-				t.Errorf("Expected body '%s', got '%s'", tt.expectedBody, bodyStr)
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Expected Content-Type application/json, got %s", ct)
+			}
+
+			got := decodeResponse(t, resp)
+			if got.Status != tt.expectedStatStr {
+				t.Errorf("Expected status %s, got %s", tt.expectedStatStr, got.Status)
+			}
+			if got.Code != tt.expectedCode {
+				t.Errorf("Expected code %s, got %s", tt.expectedCode, got.Code)
 			}
 
-			if money.Load() != tt.expectedMoney {
-				t.Errorf("Expected money %d, got %d", tt.expectedMoney, money.Load())
+			gotMoney, gotBank := balances(book)
+			if gotMoney != tt.expectedMoney {
+				t.Errorf("Expected money %d, got %d", tt.expectedMoney, gotMoney)
 			}
 
-			if bank.Load() != tt.expectedBank {
-				t.Errorf("Expected bank %d, got %d", tt.expectedBank, bank.Load())
+			if gotBank != tt.expectedBank {
+				t.Errorf("Expected bank %d, got %d", tt.expectedBank, gotBank)
 			}
 		})
 	}
 }
 
 func TestSaveHandler(t *testing.T) {
-	defer func() { money.Store(1000); bank.Store(0) }()
-	money.Store(1000)
-	bank.Store(0)
+	defer func() { book = newTestBook() }()
 
 	tests := []struct {
-		name           string
-		requestBody    string
-		expectedStatus int
-		expectedBody   string
-		expectedMoney  int64
-		expectedBank   int64
+		name            string
+		requestBody     any
+		expectedStatus  int
+		expectedStatStr protocol.Status
+		expectedCode    protocol.Code
+		expectedMoney   int64
+		expectedBank    int64
 	}{
 		{
-			name:           "Successful Transfer",
-			requestBody:    "200",
-			expectedStatus: 200,
-			expectedBody:   "current balance: 800, current bank: 200",
-			expectedMoney:  800,
-			expectedBank:   200,
+			name:            "Successful Transfer",
+			requestBody:     protocol.Request{Amount: 200, Currency: "USD"},
+			expectedStatus:  http.StatusOK,
+			expectedStatStr: protocol.StatusOK,
+			expectedMoney:   800,
+			expectedBank:    200,
 		},
 		{
-			name:           "Insufficient Funds for Transfer",
-			requestBody:    "1500",
-			expectedStatus: 200,
-			expectedBody:   "low balance for bank transfer",
-			expectedMoney:  1000,
-			expectedBank:   0,
+			name:            "Insufficient Funds for Transfer",
+			requestBody:     protocol.Request{Amount: 1500, Currency: "USD"},
+			expectedStatus:  http.StatusPaymentRequired,
+			expectedStatStr: protocol.StatusError,
+			expectedCode:    protocol.CodeInsufficientFunds,
+			expectedMoney:   1000,
+			expectedBank:    0,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			money.Store(1000)
-			bank.Store(0)
+			book = newTestBook()
 
-			req := httptest.NewRequest("POST", "/save", bytes.NewBufferString(tt.requestBody))
+			req := httptest.NewRequest("POST", "/save", jsonBody(tt.requestBody))
 			req = req.WithContext(context.WithValue(req.Context(), RequestIDContextKey, "test-id"))
 
 			w := httptest.NewRecorder()
@@ -235,26 +323,32 @@ func TestSaveHandler(t *testing.T) {
 			resp := w.Result()
 			defer resp.Body.Close()
 
-			body, _ := io.ReadAll(resp.Body)
-			bodyStr := strings.TrimSpace(string(body))
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
 
-			if bodyStr != tt.expectedBody {
-				t.Errorf("Expected body '%s', got '%s'", tt.expectedBody, bodyStr)
+			got := decodeResponse(t, resp)
+			if got.Status != tt.expectedStatStr {
+				t.Errorf("Expected status %s, got %s", tt.expectedStatStr, got.Status)
+			}
+			if got.Code != tt.expectedCode {
+				t.Errorf("Expected code %s, got %s", tt.expectedCode, got.Code)
 			}
 
-			if money.Load() != tt.expectedMoney {
-				t.Errorf("Expected money %d, got %d", tt.expectedMoney, money.Load())
+			gotMoney, gotBank := balances(book)
+			if gotMoney != tt.expectedMoney {
+				t.Errorf("Expected money %d, got %d", tt.expectedMoney, gotMoney)
 			}
 
-			if bank.Load() != tt.expectedBank {
-				t.Errorf("Expected bank %d, got %d", tt.expectedBank, bank.Load())
+			if gotBank != tt.expectedBank {
+				t.Errorf("Expected bank %d, got %d", tt.expectedBank, gotBank)
 			}
 		})
 	}
 }
 
 func TestConcurrentPayments(t *testing.T) {
-	defer func() { money.Store(1000); bank.Store(0) }()
+	defer func() { book = newTestBook() }()
 
 	const (
 		initialBalance = 1000
@@ -262,8 +356,7 @@ func TestConcurrentPayments(t *testing.T) {
 		paymentAmount  = 10
 	)
 
-	money.Store(initialBalance)
-	bank.Store(0)
+	book = newTestBook()
 
 	var wg sync.WaitGroup
 	errors := make(chan error, numRequests)
@@ -275,7 +368,7 @@ func TestConcurrentPayments(t *testing.T) {
 			defer wg.Done()
 
 			req := httptest.NewRequest("POST", "/pay",
-				bytes.NewBufferString(strconv.Itoa(paymentAmount)))
+				jsonBody(protocol.Request{Amount: paymentAmount, Currency: "USD"}))
 			req = req.WithContext(context.WithValue(req.Context(),
 				RequestIDContextKey, fmt.Sprintf("conc-test-%d", id)))
 
@@ -285,16 +378,15 @@ func TestConcurrentPayments(t *testing.T) {
 			resp := w.Result()
 			defer resp.Body.Close()
 
-			body, _ := io.ReadAll(resp.Body)
+			got := decodeResponse(t, resp)
 
-			if resp.StatusCode == 200 {
-				if strings.Contains(string(body), "current balance") {
-					successfulPayments.Add(1)
-				} else if !strings.Contains(string(body), "low balance") {
-					errors <- fmt.Errorf("unexpected response: %s", string(body))
-				}
-			} else {
-				errors <- fmt.Errorf("unexpected status: %d", resp.StatusCode)
+			switch {
+			case resp.StatusCode == http.StatusOK && got.Status == protocol.StatusOK:
+				successfulPayments.Add(1)
+			case resp.StatusCode == http.StatusPaymentRequired && got.Code == protocol.CodeInsufficientFunds:
+				// expected once the wallet is drained
+			default:
+				errors <- fmt.Errorf("unexpected response: status=%d code=%s", resp.StatusCode, got.Code)
 			}
 		}(i)
 	}
@@ -314,7 +406,7 @@ func TestConcurrentPayments(t *testing.T) {
 			actualSuccessful, expectedSuccessful)
 	}
 
-	finalBalance := money.Load()
+	finalBalance, _ := balances(book)
 	if finalBalance < 0 {
 		t.Errorf("Balance went negative: %d", finalBalance)
 	}
@@ -382,10 +474,10 @@ func TestResponseWriter(t *testing.T) {
 }
 
 func TestFullMiddlewareChain(t *testing.T) {
-	money.Store(1000)
-	defer func() { money.Store(1000); bank.Store(0) }()
+	book = newTestBook()
+	defer func() { book = newTestBook() }()
 
-	payHandlerChain := RequestIDMiddleware(
+	payHandlerChain := RequestIDMiddleware(testLogger())(
 		LoggingMiddleware(
 			http.HandlerFunc(payHandler),
 		),
@@ -397,16 +489,16 @@ func TestFullMiddlewareChain(t *testing.T) {
 	client := &http.Client{}
 
 	req1, _ := http.NewRequest("POST", server.URL+"/pay",
-		bytes.NewBufferString("300"))
+		jsonBody(protocol.Request{Amount: 300, Currency: "USD"}))
 	resp1, err := client.Do(req1)
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer resp1.Body.Close()
 
-	body1, _ := io.ReadAll(resp1.Body)
-	if !strings.Contains(string(body1), "current balance: 700") {
-		t.Errorf("Full chain payment failed. Response: %s", string(body1))
+	got := decodeResponse(t, resp1)
+	if got.Status != protocol.StatusOK || got.Balance != 700 {
+		t.Errorf("Full chain payment failed. Response: %+v", got)
 	}
 
 	if resp1.Header.Get(RequestIDHeader) == "" {
@@ -414,19 +506,156 @@ func TestFullMiddlewareChain(t *testing.T) {
 	}
 }
 
+func TestTransferRequiresOwnershipOfFromAccount(t *testing.T) {
+	authSecret := []byte("test-secret")
+
+	book = accounts.NewMemoryBook(
+		accounts.Account{ID: "wallet:alice", Owner: "alice", Type: accounts.Asset, Balance: 500},
+		accounts.Account{ID: "wallet:mallory", Owner: "mallory", Type: accounts.Asset, Balance: 500},
+	)
+	defer func() { book = newTestBook() }()
+
+	handler := middleware.AuthMiddleware(authSecret)(http.HandlerFunc(transferHandler))
+
+	req := httptest.NewRequest("POST", "/transfer", jsonBody(transferRequest{
+		From: "wallet:alice", To: "wallet:mallory", Amount: 100,
+	}))
+	req.Header.Set("Authorization", "Bearer "+middleware.SignToken(authSecret, "mallory"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != protocol.CodeForbidden.HTTPStatus() {
+		t.Fatalf("expected forbidden transfer to be rejected with %d, got %d: %s",
+			protocol.CodeForbidden.HTTPStatus(), w.Code, w.Body.String())
+	}
+
+	if a, _ := book.Account("wallet:alice"); a.Balance != 500 {
+		t.Errorf("forbidden transfer must not move funds, wallet:alice balance is %d", a.Balance)
+	}
+}
+
+func TestTransferAllowsAdminAcrossAccounts(t *testing.T) {
+	authSecret := []byte("test-secret")
+
+	book = accounts.NewMemoryBook(
+		accounts.Account{ID: "wallet:alice", Owner: "alice", Type: accounts.Asset, Balance: 500},
+		accounts.Account{ID: "wallet:mallory", Owner: "mallory", Type: accounts.Asset, Balance: 500},
+	)
+	defer func() { book = newTestBook() }()
+
+	handler := middleware.AuthMiddleware(authSecret)(http.HandlerFunc(transferHandler))
+
+	req := httptest.NewRequest("POST", "/transfer", jsonBody(transferRequest{
+		From: "wallet:alice", To: "wallet:mallory", Amount: 100,
+	}))
+	req.Header.Set("Authorization", "Bearer "+middleware.SignToken(authSecret, middleware.AdminCaller))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected admin transfer to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if a, _ := book.Account("wallet:alice"); a.Balance != 400 {
+		t.Errorf("expected wallet:alice debited to 400, got %d", a.Balance)
+	}
+}
+
+func TestNewCallerMustBeFundedByAdminBeforePaying(t *testing.T) {
+	authSecret := []byte("test-secret")
+
+	book = accounts.NewMemoryBook(
+		accounts.Account{ID: accounts.External, Owner: "system", Type: accounts.Liability, Balance: 0},
+	)
+	defer func() { book = newTestBook() }()
+
+	payAsBob := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/pay", jsonBody(protocol.Request{Amount: 100, Currency: "USD"}))
+		req.Header.Set("Authorization", "Bearer "+middleware.SignToken(authSecret, "bob"))
+		req = req.WithContext(context.WithValue(req.Context(), RequestIDContextKey, "test-id"))
+		w := httptest.NewRecorder()
+		middleware.AuthMiddleware(authSecret)(http.HandlerFunc(payHandler)).ServeHTTP(w, req)
+		return w
+	}
+
+	// A freshly auto-provisioned wallet starts at 0, so /pay is refused
+	// until an admin funds it.
+	if w := payAsBob(); w.Code != http.StatusPaymentRequired {
+		t.Fatalf("expected an unfunded wallet to be refused, got %d: %s", w.Code, w.Body.String())
+	}
+
+	fundReq := httptest.NewRequest("POST", "/transfer", jsonBody(transferRequest{
+		From: accounts.External, To: "wallet:bob", Amount: 500,
+	}))
+	fundReq.Header.Set("Authorization", "Bearer "+middleware.SignToken(authSecret, middleware.AdminCaller))
+	fundW := httptest.NewRecorder()
+	middleware.AuthMiddleware(authSecret)(http.HandlerFunc(transferHandler)).ServeHTTP(fundW, fundReq)
+	if fundW.Code != http.StatusOK {
+		t.Fatalf("expected admin funding transfer to succeed, got %d: %s", fundW.Code, fundW.Body.String())
+	}
+
+	if w := payAsBob(); w.Code != http.StatusOK {
+		t.Errorf("expected the funded wallet to pay successfully, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAccountsListScopedToCaller(t *testing.T) {
+	authSecret := []byte("test-secret")
+
+	book = accounts.NewMemoryBook(
+		accounts.Account{ID: "wallet:alice", Owner: "alice", Type: accounts.Asset, Balance: 500},
+		accounts.Account{ID: "wallet:mallory", Owner: "mallory", Type: accounts.Asset, Balance: 500},
+	)
+	defer func() { book = newTestBook() }()
+
+	handler := middleware.AuthMiddleware(authSecret)(http.HandlerFunc(accountsHandler))
+
+	req := httptest.NewRequest("GET", "/accounts", nil)
+	req.Header.Set("Authorization", "Bearer "+middleware.SignToken(authSecret, "alice"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var got []accounts.Account
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "wallet:alice" {
+		t.Errorf("expected /accounts scoped to alice's own account, got %+v", got)
+	}
+}
+
+func TestAccountByIDForbidsOtherCallers(t *testing.T) {
+	authSecret := []byte("test-secret")
+
+	book = accounts.NewMemoryBook(
+		accounts.Account{ID: "wallet:alice", Owner: "alice", Type: accounts.Asset, Balance: 500},
+	)
+	defer func() { book = newTestBook() }()
+
+	handler := middleware.AuthMiddleware(authSecret)(http.HandlerFunc(accountHandler))
+
+	req := httptest.NewRequest("GET", "/accounts/wallet:alice", nil)
+	req.Header.Set("Authorization", "Bearer "+middleware.SignToken(authSecret, "mallory"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 func TestEdgeCases(t *testing.T) {
 	tests := []struct {
 		name        string
 		handler     http.HandlerFunc
-		requestBody string
+		requestBody any
 		setup       func()
 		check       func(t *testing.T, moneyVal, bankVal int64)
 	}{
 		{
 			name:        "Zero Amount Payment",
 			handler:     payHandler,
-			requestBody: "0",
-			setup:       func() { money.Store(1000) },
+			requestBody: protocol.Request{Amount: 0, Currency: "USD"},
+			setup:       func() { book = newTestBook() },
 			check: func(t *testing.T, moneyVal, bankVal int64) {
 				if moneyVal != 1000 {
 					t.Errorf("Zero amount should not change balance: got %d", moneyVal)
@@ -436,8 +665,8 @@ func TestEdgeCases(t *testing.T) {
 		{
 			name:        "Negative Amount",
 			handler:     payHandler,
-			requestBody: "-100",
-			setup:       func() { money.Store(1000) },
+			requestBody: protocol.Request{Amount: -100, Currency: "USD"},
+			setup:       func() { book = newTestBook() },
 			check: func(t *testing.T, moneyVal, bankVal int64) {
 				if moneyVal != 1000 {
 					t.Errorf("Negative amount should not change balance: got %d", moneyVal)
@@ -447,8 +676,8 @@ func TestEdgeCases(t *testing.T) {
 		{
 			name:        "Very Large Amount",
 			handler:     payHandler,
-			requestBody: "999999999999999999",
-			setup:       func() { money.Store(1000) },
+			requestBody: protocol.Request{Amount: 999999999999999999, Currency: "USD"},
+			setup:       func() { book = newTestBook() },
 			check: func(t *testing.T, moneyVal, bankVal int64) {
 				if moneyVal != 1000 {
 					t.Errorf("Large amount should not change balance: got %d", moneyVal)
@@ -462,10 +691,9 @@ func TestEdgeCases(t *testing.T) {
 			if tt.setup != nil {
 				tt.setup()
 			}
-			defer func() { money.Store(1000); bank.Store(0) }()
+			defer func() { book = newTestBook() }()
 
-			req := httptest.NewRequest("POST", "/test",
-				bytes.NewBufferString(tt.requestBody))
+			req := httptest.NewRequest("POST", "/test", jsonBody(tt.requestBody))
 			req = req.WithContext(context.WithValue(req.Context(),
 				RequestIDContextKey, "edge-test"))
 
@@ -473,12 +701,9 @@ func TestEdgeCases(t *testing.T) {
 			tt.handler(w, req)
 
 			if tt.check != nil {
-				tt.check(t, money.Load(), bank.Load())
+				moneyVal, bankVal := balances(book)
+				tt.check(t, moneyVal, bankVal)
 			}
 		})
 	}
 }
-
-var logOutput = func(format string, args ...interface{}) {
-	fmt.Printf(format, args...)
-}
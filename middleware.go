@@ -4,16 +4,39 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
+
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/logging"
 )
 
 type RequestIDKey string
 
+type traceContextKey string
+
 const (
 	RequestIDHeader                  = "X-Request-ID"
 	RequestIDContextKey RequestIDKey = "requestID"
+
+	// TraceParentHeader is the W3C trace-context header: RequestIDMiddleware
+	// reads it on the way in and writes it on the way out.
+	TraceParentHeader = "traceparent"
+
+	traceContextValueKey traceContextKey = "trace"
 )
 
+// TraceContext is the W3C trace-context identifying a request: a trace
+// ID shared by every hop of the request and a span ID unique to this
+// one, so a handler can start a child span that still rolls up under
+// the same trace.
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+}
+
 func generateRequestID() string {
 	b := make([]byte, 16)
 	if _, err := rand.Read(b); err != nil {
@@ -23,24 +46,66 @@ func generateRequestID() string {
 	return base64.RawURLEncoding.EncodeToString(b)
 }
 
-func RequestIDMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// generateHexID returns n random bytes hex-encoded, for trace/span IDs
+// which the W3C spec requires to be lowercase hex of a fixed length.
+func generateHexID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return strings.Repeat("0", n*2)
+	}
+	return hex.EncodeToString(b)
+}
 
-		requestID := r.Header.Get(RequestIDHeader)
-		if requestID == "" || requestID == " " {
-			requestID = generateRequestID()
-		}
+// parseTraceParent extracts the trace ID and parent span ID from a W3C
+// traceparent header ("version-traceid-parentid-flags"). ok is false if
+// header is empty or malformed, in which case the caller should start a
+// new trace rather than continue one.
+func parseTraceParent(header string) (traceID, parentSpanID string, ok bool) {
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// RequestIDMiddleware assigns (or reuses) a request ID, accepts or
+// starts a W3C trace, and attaches a logger carrying both to the
+// request's context so every handler downstream can retrieve it with
+// logging.FromContext instead of threading it through explicitly.
+func RequestIDMiddleware(base *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" || requestID == " " {
+				requestID = generateRequestID()
+			}
 
-		ctx := context.WithValue(r.Context(), RequestIDContextKey, requestID)
+			traceID, _, ok := parseTraceParent(r.Header.Get(TraceParentHeader))
+			if !ok {
+				traceID = generateHexID(16)
+			}
+			spanID := generateHexID(8)
 
-		r = r.WithContext(ctx)
+			ctx := context.WithValue(r.Context(), RequestIDContextKey, requestID)
+			ctx = context.WithValue(ctx, traceContextValueKey, TraceContext{TraceID: traceID, SpanID: spanID})
+			ctx = logging.NewContext(ctx, base.With(
+				"request_id", requestID,
+				"trace_id", traceID,
+				"span_id", spanID,
+			))
 
-		w.Header().Set(RequestIDHeader, requestID)
+			r = r.WithContext(ctx)
 
-		next.ServeHTTP(w, r)
-	})
+			w.Header().Set(RequestIDHeader, requestID)
+			w.Header().Set(TraceParentHeader, fmt.Sprintf("00-%s-%s-01", traceID, spanID))
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
+// GetRequestID returns the request ID RequestIDMiddleware attached to
+// ctx, or "" if none is present.
 func GetRequestID(ctx context.Context) string {
 	if ctx != nil {
 		if str, ok := ctx.Value(RequestIDContextKey).(string); ok {
@@ -49,3 +114,10 @@ func GetRequestID(ctx context.Context) string {
 	}
 	return ""
 }
+
+// GetTraceContext returns the W3C trace context RequestIDMiddleware
+// attached to ctx, if any.
+func GetTraceContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextValueKey).(TraceContext)
+	return tc, ok
+}
@@ -0,0 +1,19 @@
+// Package middleware provides a small, dependency-free toolkit for
+// composing net/http middlewares and mounting routes, plus a handful of
+// production-grade middlewares (rate limiting, panic recovery, bearer
+// token auth) that any handler in this service can opt into.
+package middleware
+
+import "net/http"
+
+// Chain composes mws into a single middleware that applies them in
+// order: Chain(a, b, c)(h) wraps h as a(b(c(h))), so a sees the request
+// first and c sees it last, immediately before h.
+func Chain(mws ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			h = mws[i](h)
+		}
+		return h
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func marker(tag string, trail *[]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*trail = append(*trail, tag)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestChainRunsMiddlewaresInOrder(t *testing.T) {
+	var trail []string
+	handler := Chain(marker("a", &trail), marker("b", &trail), marker("c", &trail))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			trail = append(trail, "handler")
+		}),
+	)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []string{"a", "b", "c", "handler"}
+	if len(trail) != len(want) {
+		t.Fatalf("expected trail %v, got %v", want, trail)
+	}
+	for i, tag := range want {
+		if trail[i] != tag {
+			t.Errorf("expected trail %v, got %v", want, trail)
+			break
+		}
+	}
+}
+
+func TestChainWithNoMiddlewaresIsIdentity(t *testing.T) {
+	called := false
+	handler := Chain()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !called {
+		t.Error("expected the wrapped handler to run")
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a keyed token bucket: each key gets its own bucket of
+// burst tokens that refill at rps per second, so a burst of traffic from
+// one caller doesn't starve another.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	rps     float64
+	burst   float64
+}
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing rps requests per second
+// per key, with bursts up to burst requests.
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		buckets: make(map[string]*bucket),
+		rps:     rps,
+		burst:   float64(burst),
+	}
+}
+
+// Allow reports whether a request for key may proceed, consuming a
+// token if so.
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens = min(l.burst, b.tokens+elapsed*l.rps)
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RateLimitMiddleware rejects requests over limiter's configured rate
+// with 429 Too Many Requests. Requests are keyed by the caller identity
+// AuthMiddleware put in context, if any, otherwise by remote IP.
+func RateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.Allow(rateLimitKey(r)) {
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rateLimitKey(r *http.Request) string {
+	if caller, ok := CallerFromContext(r.Context()); ok {
+		return "caller:" + caller
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimiterAllowsUpToBurstThenRejects(t *testing.T) {
+	limiter := NewRateLimiter(0, 3)
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("client-a") {
+			t.Fatalf("expected request %d to be allowed within burst", i)
+		}
+	}
+	if limiter.Allow("client-a") {
+		t.Error("expected request beyond burst to be rejected")
+	}
+}
+
+func TestRateLimiterTracksKeysIndependently(t *testing.T) {
+	limiter := NewRateLimiter(0, 1)
+
+	if !limiter.Allow("client-a") {
+		t.Fatal("expected first request from client-a to be allowed")
+	}
+	if !limiter.Allow("client-b") {
+		t.Error("expected client-b's bucket to be independent of client-a's")
+	}
+}
+
+func TestRateLimitMiddlewareRejectsOverLimit(t *testing.T) {
+	limiter := NewRateLimiter(0, 1)
+	handler := RateLimitMiddleware(limiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.1:1234"
+
+	w1 := httptest.NewRecorder()
+	handler.ServeHTTP(w1, req)
+	if w1.Code != http.StatusOK {
+		t.Fatalf("expected first request to succeed, got %d", w1.Code)
+	}
+
+	w2 := httptest.NewRecorder()
+	handler.ServeHTTP(w2, req)
+	if w2.Code != http.StatusTooManyRequests {
+		t.Errorf("expected second request to be rate limited, got %d", w2.Code)
+	}
+}
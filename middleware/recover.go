@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/logging"
+)
+
+// RecoverMiddleware converts a panic anywhere downstream into a 500
+// response instead of crashing the server, logging it through whatever
+// logger logging.FromContext finds in the request's context (already
+// carrying request/trace fields if RequestIDMiddleware ran upstream).
+func RecoverMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					logging.FromContext(r.Context()).Error("panic recovered", "panic", rec)
+					http.Error(w, "internal server error", http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
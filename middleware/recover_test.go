@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Rkmfm2523/Bank_HTTP_With_MiddleWare/logging"
+)
+
+func TestRecoverMiddlewareConvertsPanicTo500(t *testing.T) {
+	handler := RecoverMiddleware()(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}),
+	)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestRecoverMiddlewareLogsPanicAsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	handler := RecoverMiddleware()(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(logging.NewContext(req.Context(), logger.With("request_id", "req-1")))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("expected a JSON log record, got %q: %v", buf.String(), err)
+	}
+	if rec["request_id"] != "req-1" {
+		t.Errorf("expected the panic log to carry request_id, got %+v", rec)
+	}
+	if rec["panic"] != "boom" {
+		t.Errorf("expected the panic log to carry the recovered value, got %+v", rec)
+	}
+}
+
+func TestRecoverMiddlewarePassesThroughWithoutPanic(t *testing.T) {
+	handler := RecoverMiddleware()(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusTeapot {
+		t.Errorf("expected status %d, got %d", http.StatusTeapot, w.Code)
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistryAppliesBaseThenRouteMiddlewares(t *testing.T) {
+	var trail []string
+	base := Chain(marker("base", &trail))
+
+	mux := http.NewServeMux()
+	reg := NewRegistry(mux, base)
+	reg.HandleFunc("/widgets", func(w http.ResponseWriter, r *http.Request) {
+		trail = append(trail, "handler")
+	}, marker("route", &trail))
+
+	req := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	want := []string{"base", "route", "handler"}
+	if len(trail) != len(want) {
+		t.Fatalf("expected trail %v, got %v", want, trail)
+	}
+	for i, tag := range want {
+		if trail[i] != tag {
+			t.Errorf("expected trail %v, got %v", want, trail)
+			break
+		}
+	}
+}
+
+func TestRegistryHandleFuncWithNoExtraMiddlewares(t *testing.T) {
+	mux := http.NewServeMux()
+	reg := NewRegistry(mux, Chain())
+	reg.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
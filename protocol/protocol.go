@@ -0,0 +1,63 @@
+// Package protocol defines the versioned JSON wire format shared by the
+// bank's HTTP handlers and the client package, so callers never have to
+// string-match free-form response text.
+package protocol
+
+// Version is the protocol version these types describe. It is not yet
+// sent on the wire, but exists so a future v2 can live alongside it.
+const Version = 1
+
+// Status is the top-level outcome of a request.
+type Status string
+
+const (
+	StatusOK    Status = "OK"
+	StatusError Status = "ERROR"
+)
+
+// Code identifies the specific error when Status is StatusError.
+type Code string
+
+const (
+	CodeInsufficientFunds Code = "INSUFFICIENT_FUNDS"
+	CodeInvalidArguments  Code = "INVALID_ARGUMENTS"
+	CodeForbidden         Code = "FORBIDDEN"
+	CodeServerError       Code = "SERVER_ERROR"
+)
+
+// Request is the body accepted by /pay and /save.
+type Request struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Memo     string `json:"memo,omitempty"`
+}
+
+// Response is the body returned by /pay, /save and /transfer, on success
+// and on error. /accounts/{id} and the auth and rate-limit middlewares
+// report their errors as plain text instead.
+type Response struct {
+	Status    Status `json:"status"`
+	Code      Code   `json:"code,omitempty"`
+	Message   string `json:"message,omitempty"`
+	Balance   int64  `json:"balance"`
+	Bank      int64  `json:"bank"`
+	RequestID string `json:"request_id"`
+	TxID      string `json:"tx_id,omitempty"`
+}
+
+// HTTPStatus maps a Code to the HTTP status code the handlers send it
+// with.
+func (c Code) HTTPStatus() int {
+	switch c {
+	case CodeInvalidArguments:
+		return 400
+	case CodeInsufficientFunds:
+		return 402
+	case CodeForbidden:
+		return 403
+	case CodeServerError:
+		return 500
+	default:
+		return 500
+	}
+}
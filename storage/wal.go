@@ -0,0 +1,181 @@
+// Package storage provides a generic write-ahead log and snapshot helper
+// used to persist state across a restart without replaying an unbounded
+// history: accounts.Book is the current consumer.
+//
+// This is a deliberately narrower shape than a pluggable Ledger interface
+// with swappable in-memory/BoltDB/SQLite backends: accounts.Book only
+// ever needed the one (file-backed, JSON-lines) implementation. Revisit
+// if a second consumer needs a different backend.
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// record is the on-disk envelope for one WAL[T] entry: a monotonic
+// sequence number plus the caller's value.
+type record[T any] struct {
+	Seq   uint64 `json:"seq"`
+	Value T      `json:"value"`
+}
+
+// ReplayWAL reads the JSON-lines log at path, if it exists, calling visit
+// once per entry in order. It returns the sequence number of the last
+// entry replayed (0 if the file does not exist or is empty), so the
+// caller can resume appending from there.
+func ReplayWAL[T any](path string, visit func(seq uint64, v T) error) (last uint64, err error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec record[T]
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return last, fmt.Errorf("storage: corrupt wal entry after seq %d: %w", last, err)
+		}
+		if err := visit(rec.Seq, rec.Value); err != nil {
+			return last, err
+		}
+		last = rec.Seq
+	}
+	return last, scanner.Err()
+}
+
+// WAL is a generic append-only, newline-delimited JSON-lines log. Entries
+// are numbered sequentially starting after startSeq so a fresh WAL can
+// continue a sequence recovered by ReplayWAL or a snapshot.
+type WAL[T any] struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	seq  uint64
+}
+
+// OpenWAL opens (creating if necessary) the log at path for appending,
+// continuing the sequence from startSeq.
+func OpenWAL[T any](path string, startSeq uint64) (*WAL[T], error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &WAL[T]{path: path, f: f, seq: startSeq}, nil
+}
+
+// Append writes v as the next entry and fsyncs before returning, so a
+// caller that has seen a nil error can rely on the entry surviving a
+// crash.
+func (w *WAL[T]) Append(v T) (seq uint64, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	rec := record[T]{Seq: w.seq + 1, Value: v}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return 0, err
+	}
+	line = append(line, '\n')
+
+	if _, err := w.f.Write(line); err != nil {
+		return 0, err
+	}
+	if err := w.f.Sync(); err != nil {
+		return 0, err
+	}
+
+	w.seq = rec.Seq
+	return w.seq, nil
+}
+
+// Seq returns the sequence number of the last entry appended.
+func (w *WAL[T]) Seq() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.seq
+}
+
+// Reset truncates the log and continues the sequence from newSeq. Callers
+// use this after writing a snapshot that captures everything the log held.
+func (w *WAL[T]) Reset(newSeq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	w.f = f
+	w.seq = newSeq
+	return nil
+}
+
+// Close releases the underlying file handle.
+func (w *WAL[T]) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+// SaveSnapshot writes v to path as JSON, overwriting any existing file.
+// It writes to a temp file in the same directory first and renames it
+// into place, so a crash mid-write can never leave a truncated snapshot
+// for LoadSnapshot to trip over.
+func SaveSnapshot[S any](path string, v S) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot reads and unmarshals the snapshot at path. ok is false if
+// no snapshot file exists yet.
+func LoadSnapshot[S any](path string) (v S, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return v, false, nil
+	}
+	if err != nil {
+		return v, false, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, false, err
+	}
+	return v, true, nil
+}
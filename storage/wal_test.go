@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type snap struct {
+	Seq uint64 `json:"seq"`
+}
+
+func TestSaveSnapshotThenLoadSnapshotRoundTrips(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	if err := SaveSnapshot(path, snap{Seq: 42}); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	got, ok, err := LoadSnapshot[snap](path)
+	if err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	if !ok || got.Seq != 42 {
+		t.Errorf("expected Seq=42, got %+v (ok=%v)", got, ok)
+	}
+}
+
+func TestSaveSnapshotLeavesNoTempFileBehind(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "snapshot.json")
+
+	if err := SaveSnapshot(path, snap{Seq: 1}); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "snapshot.json" {
+		t.Errorf("expected only snapshot.json in %s, got %v", dir, entries)
+	}
+}